@@ -3,14 +3,15 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	colorjson "github.com/TylerBrock/colorjson"
 	"github.com/yalp/jsonpath"
@@ -21,6 +22,8 @@ type TestCase struct {
 	Target string                 `yaml:"target"`
 	In     map[string]interface{} `yaml:"in"`
 	Out    map[string]interface{} `yaml:"out"`
+	Retry  *RetrySpec             `yaml:"retry"`
+	Steps  []Step                 `yaml:"steps"`
 }
 
 type Error struct {
@@ -28,14 +31,6 @@ type Error struct {
 	actualKey   string
 	expectedKey string
 	category    string
-	entry       Entry
-}
-
-var errors []Error
-
-type Context struct {
-	variables map[string]interface{}
-	steps     map[string]map[string]interface{}
 }
 
 func readVars(varsPath string) (map[string]interface{}, error) {
@@ -76,6 +71,7 @@ type EqualityOperatorCompartor func(
 	actualKey string,
 	expectedKey string,
 	inverse bool,
+	errs *[]Error,
 ) bool
 
 func checkType(value interface{}, expected string) {
@@ -95,13 +91,14 @@ func init() {
 				actualKey string,
 				expectedKey string,
 				inverse bool,
+				errs *[]Error,
 			) bool {
 				actual := actual0.(string)
 				expected := expected0.(string)
 
 				if strings.HasPrefix(expected, "$") {
 					if inverse && expected == "$string" {
-						errors = append(errors, Error{
+						*errs = append(*errs, Error{
 							message:     "Unexpected value type",
 							actualKey:   actualKey,
 							expectedKey: expectedKey + ":" + expected,
@@ -110,7 +107,7 @@ func init() {
 						return false
 					} else if !inverse && expected != "$string" {
 
-						errors = append(errors, Error{
+						*errs = append(*errs, Error{
 							message:     "Unexpected value type",
 							actualKey:   actualKey,
 							expectedKey: expectedKey + ":" + expected,
@@ -123,7 +120,7 @@ func init() {
 				}
 
 				if inverse && actual == expected {
-					errors = append(errors, Error{
+					*errs = append(*errs, Error{
 						message:     "Values are equal",
 						actualKey:   actualKey,
 						expectedKey: expectedKey,
@@ -131,7 +128,7 @@ func init() {
 					})
 					return false
 				} else if !inverse && actual != expected {
-					errors = append(errors, Error{
+					*errs = append(*errs, Error{
 						message:     "Values are not equal",
 						actualKey:   actualKey,
 						expectedKey: expectedKey,
@@ -148,6 +145,7 @@ func init() {
 				parentActualKey string,
 				parentExpectedKey string,
 				inverse bool,
+				errs *[]Error,
 			) bool {
 				actualValue := actual0.(string)
 				expectedValue := expected0.(map[string]interface{})
@@ -157,9 +155,9 @@ func init() {
 					operand2 := expectedValue[expectedKey]
 
 					if strings.HasPrefix(expectedKey, "$") {
-						result = result && operate(actualValue, expectedKey, operand2, parentActualKey, parentExpectedKey+"."+expectedKey)
+						result = result && operate(actualValue, expectedKey, operand2, parentActualKey, parentExpectedKey+"."+expectedKey, errs)
 					} else {
-						errors = append(errors, Error{
+						*errs = append(*errs, Error{
 							message:     "Cannot mix operators and fields",
 							actualKey:   parentActualKey,
 							expectedKey: parentExpectedKey,
@@ -179,12 +177,13 @@ func init() {
 				actualKey string,
 				expectedKey string,
 				inverse bool,
+				errs *[]Error,
 			) bool {
 				expected := expected0.(string)
 
 				if strings.HasPrefix(expected, "$") {
 					if expected != "$number" {
-						errors = append(errors, Error{
+						*errs = append(*errs, Error{
 							message:     "Unexpected value type",
 							actualKey:   actualKey,
 							expectedKey: expectedKey + ":" + expected,
@@ -195,7 +194,7 @@ func init() {
 					return true
 				}
 
-				errors = append(errors, Error{
+				*errs = append(*errs, Error{
 					message:     "Values are not equal",
 					actualKey:   actualKey,
 					expectedKey: expectedKey,
@@ -209,6 +208,7 @@ func init() {
 				actualKey string,
 				expectedKey string,
 				inverse bool,
+				errs *[]Error,
 			) bool {
 				if actual, err := actual0.(json.Number).Int64(); err == nil {
 					if int(actual) == expected0.(int) {
@@ -216,7 +216,7 @@ func init() {
 					}
 				}
 
-				errors = append(errors, Error{
+				*errs = append(*errs, Error{
 					message:     "Values are not equal",
 					actualKey:   actualKey,
 					expectedKey: expectedKey,
@@ -230,6 +230,7 @@ func init() {
 				actualKey string,
 				expectedKey string,
 				inverse bool,
+				errs *[]Error,
 			) bool {
 				actualValue := actual0.(json.Number)
 				expectedValue := expected0.(map[string]interface{})
@@ -239,9 +240,9 @@ func init() {
 					operand2 := expectedValue[expectedKey]
 
 					if strings.HasPrefix(expectedKey, "$") {
-						result = result && operate(actualValue, expectedKey, operand2, "", "")
+						result = result && operate(actualValue, expectedKey, operand2, "", "", errs)
 					} else {
-						errors = append(errors, Error{
+						*errs = append(*errs, Error{
 							message:     "Cannot mix operators and fields",
 							actualKey:   actualKey,
 							expectedKey: expectedKey,
@@ -254,6 +255,59 @@ func init() {
 			},
 		},
 	}
+
+	// Fallback entries so $ne (and any other consumer of equalityOperators)
+	// can compare the remaining common Go types instead of printing an
+	// "unsupported comparison" message.
+	deepEqualComparator := newDeepEqualComparator()
+	equalityOperators["bool"] = map[string]EqualityOperatorCompartor{
+		"bool": deepEqualComparator,
+	}
+	equalityOperators["float64"] = map[string]EqualityOperatorCompartor{
+		"float64": deepEqualComparator,
+	}
+	equalityOperators["[]interface {}"] = map[string]EqualityOperatorCompartor{
+		"[]interface {}": deepEqualComparator,
+	}
+	equalityOperators["map[string]interface {}"] = map[string]EqualityOperatorCompartor{
+		"map[string]interface {}": deepEqualComparator,
+	}
+}
+
+// newDeepEqualComparator returns an EqualityOperatorCompartor suitable for
+// types that do not need bespoke handling: two values of the same type are
+// compared with reflect.DeepEqual.
+func newDeepEqualComparator() EqualityOperatorCompartor {
+	return func(
+		actual0 interface{},
+		expected0 interface{},
+		actualKey string,
+		expectedKey string,
+		inverse bool,
+		errs *[]Error,
+	) bool {
+		equal := reflect.DeepEqual(actual0, expected0)
+
+		if inverse && equal {
+			*errs = append(*errs, Error{
+				message:     "Values are equal",
+				actualKey:   actualKey,
+				expectedKey: expectedKey,
+				category:    "response_error",
+			})
+			return false
+		} else if !inverse && !equal {
+			*errs = append(*errs, Error{
+				message:     "Values are not equal",
+				actualKey:   actualKey,
+				expectedKey: expectedKey,
+				category:    "response_error",
+			})
+			return false
+		}
+
+		return true
+	}
 }
 
 func executeIsOperator(
@@ -262,6 +316,7 @@ func executeIsOperator(
 	actualKey string,
 	expectedKey string,
 	inverse bool,
+	errs *[]Error,
 ) bool {
 	expectedTypeName := strings.TrimPrefix(operand2, "$")
 	actualTypeName := reflect.TypeOf(operand1).String()
@@ -269,7 +324,7 @@ func executeIsOperator(
 		if actualTypeName != expectedTypeName {
 			return true
 		} else {
-			errors = append(errors, Error{
+			*errs = append(*errs, Error{
 				message:     "Value type matched",
 				actualKey:   actualKey,
 				expectedKey: expectedKey,
@@ -281,7 +336,7 @@ func executeIsOperator(
 		if actualTypeName == expectedTypeName {
 			return true
 		} else {
-			errors = append(errors, Error{
+			*errs = append(*errs, Error{
 				message:     "Value type mismatched",
 				actualKey:   actualKey,
 				expectedKey: expectedKey,
@@ -299,12 +354,13 @@ func executeNeOperator(
 	expectedValue interface{},
 	actualKey string,
 	expectedKey string,
+	errs *[]Error,
 ) bool {
 	actualValueType := reflect.TypeOf(actualValue).String()
 	expectedValueType := reflect.TypeOf(expectedValue).String()
 
 	if comparator, okay := equalityOperators[actualValueType][expectedValueType]; okay {
-		return comparator(actualValue, expectedValue, actualKey, expectedKey, true)
+		return comparator(actualValue, expectedValue, actualKey, expectedKey, true, errs)
 	}
 
 	fmt.Printf("Makalu does not currently support %s vs %s comparisons!\n", actualValueType, expectedValueType)
@@ -316,11 +372,12 @@ func executeRegexOperator(
 	expectedValue string,
 	actualKey string,
 	expectedKey string,
+	errs *[]Error,
 ) bool {
 	actualValueType := reflect.TypeOf(actualValue0).String()
 
 	if actualValueType != "string" {
-		errors = append(errors, Error{
+		*errs = append(*errs, Error{
 			message:     "Unexpected value type",
 			actualKey:   actualKey,
 			expectedKey: expectedKey,
@@ -333,7 +390,7 @@ func executeRegexOperator(
 	matched, err := regexp.MatchString(expectedValue, actualValue)
 
 	if err != nil {
-		errors = append(errors, Error{
+		*errs = append(*errs, Error{
 			message:     "Invalid regex pattern",
 			actualKey:   actualKey,
 			expectedKey: expectedKey,
@@ -343,7 +400,7 @@ func executeRegexOperator(
 	}
 
 	if !matched {
-		errors = append(errors, Error{
+		*errs = append(*errs, Error{
 			message:     "Regex mismatch",
 			actualKey:   actualKey,
 			expectedKey: expectedKey,
@@ -354,55 +411,50 @@ func executeRegexOperator(
 	return matched
 }
 
+// operate looks up operator in matcherFactories and applies it to operand1.
+// $is, $is_not, $ne, and $regex are registered there (see matchers.go:init)
+// alongside every Gomega-style matcher, so this is the only dispatch path
+// needed; an unknown operator is recorded as a spec error.
 func operate(
 	operand1 interface{},
 	operator string,
 	operand2 interface{},
 	parentActualKey string,
 	parentExpectedKey string,
+	errs *[]Error,
 ) bool {
-	switch operator {
-	case "$is":
-	case "$is_not":
-		{
-			typeName := reflect.TypeOf(operand2).String()
-			if typeName != "string" || !strings.HasPrefix(operand2.(string), "$") {
-				errors = append(errors, Error{
-					message:     operator + " operator expects type name",
-					actualKey:   parentActualKey,
-					expectedKey: parentExpectedKey,
-					category:    "spec_error",
-				})
-				return false
-			}
-			return executeIsOperator(
-				operand1,
-				operand2.(string),
-				parentActualKey,
-				parentActualKey+"."+operator,
-				operator == "$is_not",
-			)
-		}
-	case "$ne":
-		{
-			return executeNeOperator(operand1, operand2, parentActualKey, parentExpectedKey)
-		}
-	case "$regex":
-		{
-			if reflect.TypeOf(operand2).String() != "string" {
-				errors = append(errors, Error{
-					message:     "$regex operator expects regex pattern",
-					actualKey:   parentActualKey,
-					expectedKey: parentExpectedKey,
-					category:    "spec_error",
-				})
-				return false
-			}
-			return executeRegexOperator(operand1, operand2.(string), parentActualKey, parentExpectedKey)
-		}
+	factory, known := matcherFactories[operator]
+	if !known {
+		*errs = append(*errs, Error{
+			message:     "Unknown operator " + operator,
+			actualKey:   parentActualKey,
+			expectedKey: parentExpectedKey,
+			category:    "spec_error",
+		})
+		return false
 	}
 
-	return false
+	matcher, err := factory(operand2)
+	if err != nil {
+		*errs = append(*errs, Error{
+			message:     err.Error(),
+			actualKey:   parentActualKey,
+			expectedKey: parentExpectedKey,
+			category:    "spec_error",
+		})
+		return false
+	}
+
+	matched, message := matcher.Match(operand1)
+	if !matched {
+		*errs = append(*errs, Error{
+			message:     message,
+			actualKey:   parentActualKey,
+			expectedKey: parentExpectedKey,
+			category:    "response_error",
+		})
+	}
+	return matched
 }
 
 func compareObjects(
@@ -410,6 +462,7 @@ func compareObjects(
 	expected map[string]interface{},
 	parentActualKey string,
 	parentExpectedKey string,
+	errs *[]Error,
 ) {
 	for key := range actual {
 		optionalKey := key + "?"
@@ -417,7 +470,7 @@ func compareObjects(
 		_, optionalKeyExists := expected[optionalKey]
 
 		if !keyExists && !optionalKeyExists {
-			errors = append(errors, Error{
+			*errs = append(*errs, Error{
 				message:     "Unknown key " + key,
 				actualKey:   parentActualKey + "." + key,
 				expectedKey: parentExpectedKey + ".$unknown",
@@ -439,7 +492,7 @@ func compareObjects(
 		actualValue, actualValueExists := actual[actualKey]
 		if !actualValueExists {
 			if !optional {
-				errors = append(errors, Error{
+				*errs = append(*errs, Error{
 					message:     "Cannot find required key '" + actualKey + "'",
 					actualKey:   parentActualKey + ".<" + actualKey + ">",
 					expectedKey: parentExpectedKey + "." + expectedKey,
@@ -452,25 +505,26 @@ func compareObjects(
 		expectedValue := expected[expectedKey]
 
 		if strings.HasPrefix(expectedKey, "$") {
-			operate(actualValue, expectedKey, expectedValue, parentActualKey+"."+actualKey, parentExpectedKey+"."+expectedKey)
+			operate(actualValue, expectedKey, expectedValue, parentActualKey+"."+actualKey, parentExpectedKey+"."+expectedKey, errs)
 		} else {
-			actualValueType := reflect.TypeOf(actualValue).String()
-			expectedValueType := reflect.TypeOf(expectedValue).String()
-
-			if comparator, okay := equalityOperators[actualValueType][expectedValueType]; okay {
-				comparator(
-					actualValue,
-					expectedValue,
-					parentActualKey+"."+actualKey,
-					parentExpectedKey+"."+expectedKey,
-					false,
-				)
-			} else {
-				fmt.Printf(
-					"Makalu does not currently support %s vs %s comparisons!\n",
-					actualValueType,
-					expectedValueType,
-				)
+			matcher, err := buildMatcher(expectedValue)
+			if err != nil {
+				*errs = append(*errs, Error{
+					message:     err.Error(),
+					actualKey:   parentActualKey + "." + actualKey,
+					expectedKey: parentExpectedKey + "." + expectedKey,
+					category:    "spec_error",
+				})
+				continue
+			}
+
+			if matched, message := matcher.Match(actualValue); !matched {
+				*errs = append(*errs, Error{
+					message:     message,
+					actualKey:   parentActualKey + "." + actualKey,
+					expectedKey: parentExpectedKey + "." + expectedKey,
+					category:    "response_error",
+				})
 			}
 		}
 	}
@@ -542,79 +596,117 @@ func refer(value string, context map[string]interface{}) interface{} {
 	return buffer.String()
 }
 
-func processEntry(entry Entry, context map[string]interface{}) {
+func processEntry(entry Entry, context map[string]interface{}) []Error {
 	testCase, err := readConf(entry.longName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	cleanTarget := strings.TrimSpace(testCase.Target)
+	if len(testCase.Steps) > 0 {
+		var caseErrors []Error
+		for _, step := range testCase.Steps {
+			caseErrors = append(caseErrors, processStep(entry, step, context)...)
+		}
+		return caseErrors
+	}
+
+	return processStep(entry, Step{
+		Target: testCase.Target,
+		In:     testCase.In,
+		Out:    testCase.Out,
+		Retry:  testCase.Retry,
+	}, context)
+}
+
+func processStep(entry Entry, step Step, context map[string]interface{}) []Error {
+	cleanTarget := strings.TrimSpace(step.Target)
 	if cleanTarget == "" {
-		errors = append(errors, Error{
+		return []Error{{
 			message:     "Target expected",
 			actualKey:   "",
 			expectedKey: "$root.target",
 			category:    "spec_error",
-			entry:       entry,
-		})
-		return
+		}}
 	}
 
-	target := refer(testCase.Target, context).(string)
-	fmt.Printf("[*] Executing '%s'\n", target)
+	target := refer(step.Target, context).(string)
 	parts := strings.Split(target, " ")
 	method := parts[0]
-	url := parts[1]
-
-	if method == "GET" {
-		response, err := http.Get(url)
-
-		if err != nil {
-			log.Fatalf("An error occured:\n%v", err)
-		}
-		defer response.Body.Close()
+	targetURL := parts[1]
 
-		responseBody, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fatalln(err)
-		}
-
-		var responseObject map[string]any
-		decoder := json.NewDecoder(strings.NewReader(string(responseBody)))
-		decoder.UseNumber()
-		decoder.Decode(&responseObject)
+	renderedHeaders, _ := renderTemplates(step.Headers, context).(map[string]interface{})
+	renderedQuery, _ := renderTemplates(step.Query, context).(map[string]interface{})
+	renderedAuth := renderAuth(step.Auth, context)
+	renderedBody := renderBody(step.Body, step.In, context)
 
-		printResponse(responseObject)
+	runner := NewRunner()
+	var lastResponse map[string]interface{}
 
-		compareObjects(responseObject, testCase.Out, "$root", "$root.out")
-	}
+	attempt := func() []Error {
+		var attemptErrors []Error
+		lastResponse = nil
 
-	if method == "POST" {
-		body, _ := json.Marshal(testCase.In)
-		buffer := bytes.NewBuffer(body)
-		response, err := http.Post(url, "application/json", buffer)
+		fmt.Printf("[*] Executing '%s'\n", target)
 
+		response, err := runner.Do(RunnerRequest{
+			Method:  method,
+			URL:     targetURL,
+			Headers: renderedHeaders,
+			Query:   renderedQuery,
+			Auth:    renderedAuth,
+			Body:    renderedBody,
+		})
 		if err != nil {
 			log.Fatalf("An error occured:\n%v", err)
 		}
-		defer response.Body.Close()
 
-		responseBody, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fatalln(err)
+		responseObject, isObject := response.Body.(map[string]interface{})
+		if isObject {
+			printResponse(responseObject)
+			lastResponse = responseObject
 		}
 
-		responseAsString := string(responseBody)
+		if step.Out != nil {
+			if isObject {
+				compareObjects(responseObject, step.Out, "$root", "$root.out", &attemptErrors)
+			} else {
+				attemptErrors = append(attemptErrors, Error{
+					message:     "Response body is not a JSON object",
+					actualKey:   "$root.out",
+					expectedKey: "$root.out",
+					category:    "response_error",
+				})
+			}
+		}
+
+		if step.Response != nil {
+			checkResponseAssertions(step.Response, response, &attemptErrors)
+		}
 
-		var responseObject map[string]any
-		decoder := json.NewDecoder(strings.NewReader(responseAsString))
-		decoder.UseNumber()
-		decoder.Decode(&responseObject)
+		return attemptErrors
+	}
 
-		printResponse(responseObject)
+	stepErrors := runWithRetry(step.Retry, attempt)
 
-		compareObjects(responseObject, testCase.Out, "$root", "$root.out")
+	if step.Name != "" {
+		if lastResponse == nil {
+			stepErrors = append(stepErrors, Error{
+				message:     "Response body is not a JSON object; cannot capture step context",
+				actualKey:   "$root.out",
+				expectedKey: "$root.capture",
+				category:    "response_error",
+			})
+		} else {
+			steps, _ := context["steps"].(map[string]interface{})
+			if steps == nil {
+				steps = map[string]interface{}{}
+				context["steps"] = steps
+			}
+			steps[step.Name] = buildStepContext(step.Name, lastResponse, step.Capture, &stepErrors)
+		}
 	}
+
+	return stepErrors
 }
 
 func isPathValid(path string) (bool, error) {
@@ -629,6 +721,14 @@ func isPathValid(path string) (bool, error) {
 }
 
 func main() {
+	reportFlag := flag.String("report", "console", "comma-separated list of reporters to run, e.g. junit:out.xml,console")
+	flag.Parse()
+
+	reporter, err := parseReportFlag(*reportFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -654,25 +754,19 @@ func main() {
 		context["vars"] = variables
 	}
 
-	for _, entry := range entries {
-		processEntry(entry, context)
-	}
+	reporter.StartSuite()
 
-	if len(errors) > 0 {
-		fmt.Println()
-	}
+	for _, entry := range entries {
+		reporter.StartCase(entry)
+		start := time.Now()
 
-	for index, item := range errors {
-		fmt.Printf("%s\n[%s] %s\n    actual path   -- %s\n    expected path -- %s\n",
-			item.entry.shortName,
-			item.category,
-			item.message,
-			item.actualKey,
-			item.expectedKey,
-		)
-		if index+1 < len(errors) {
-			fmt.Println()
+		caseErrors := processEntry(entry, context)
+		for _, caseError := range caseErrors {
+			reporter.RecordError(caseError)
 		}
+
+		reporter.EndCase(len(caseErrors) == 0, time.Since(start))
 	}
 
+	reporter.EndSuite()
 }
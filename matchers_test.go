@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// decodeJSON mirrors the way runner.go decodes response bodies, so tests
+// exercise matchers against the same json.Number/[]interface{} shapes a
+// real response produces.
+func decodeJSON(t *testing.T, text string) interface{} {
+	t.Helper()
+
+	decoder := json.NewDecoder(bytes.NewBufferString(text))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Fatalf("decoding %q: %v", text, err)
+	}
+	return decoded
+}
+
+func mustBuildMatcher(t *testing.T, operand interface{}) Matcher {
+	t.Helper()
+
+	matcher, err := buildMatcher(operand)
+	if err != nil {
+		t.Fatalf("buildMatcher(%v): %v", operand, err)
+	}
+	return matcher
+}
+
+func TestObjectMatcherNestedNumber(t *testing.T) {
+	actual := decodeJSON(t, `{"user":{"name":"bob","age":30}}`)
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "bob",
+			"age":  30,
+		},
+	}
+
+	matcher := mustBuildMatcher(t, expected)
+	if matched, message := matcher.Match(actual); !matched {
+		t.Fatalf("expected nested object to match, got: %s", message)
+	}
+}
+
+func TestObjectMatcherNestedOperator(t *testing.T) {
+	actual := decodeJSON(t, `{"user":{"name":"bob","age":30}}`)
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "bob",
+			"age":  map[string]interface{}{"$be_numerically": map[string]interface{}{"$op": ">=", "value": 18}},
+		},
+	}
+
+	matcher := mustBuildMatcher(t, expected)
+	if matched, message := matcher.Match(actual); !matched {
+		t.Fatalf("expected nested operator to match, got: %s", message)
+	}
+}
+
+func TestObjectMatcherUnknownKey(t *testing.T) {
+	actual := decodeJSON(t, `{"user":{"name":"bob","extra":"surprise"}}`)
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{"name": "bob"},
+	}
+
+	matcher := mustBuildMatcher(t, expected)
+	if matched, _ := matcher.Match(actual); matched {
+		t.Fatal("expected unknown nested key to fail the match")
+	}
+}
+
+func TestObjectMatcherOptionalKey(t *testing.T) {
+	actual := decodeJSON(t, `{"user":{"name":"bob"}}`)
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{"name": "bob", "nickname?": "bobby"},
+	}
+
+	matcher := mustBuildMatcher(t, expected)
+	if matched, message := matcher.Match(actual); !matched {
+		t.Fatalf("expected missing optional key to still match, got: %s", message)
+	}
+}
+
+func TestConsistOfNumeric(t *testing.T) {
+	actual := decodeJSON(t, `{"ids":[1,2,3]}`)
+	actualIDs := actual.(map[string]interface{})["ids"]
+
+	matcher, err := newConsistOfMatcher([]interface{}{1, 2, 3})
+	if err != nil {
+		t.Fatalf("newConsistOfMatcher: %v", err)
+	}
+
+	if matched, message := matcher.Match(actualIDs); !matched {
+		t.Fatalf("expected exact numeric match, got: %s", message)
+	}
+}
+
+func TestConsistOfAnyOrder(t *testing.T) {
+	actual := decodeJSON(t, `{"ids":[3,1,2]}`)
+	actualIDs := actual.(map[string]interface{})["ids"]
+
+	matcher, err := newConsistOfMatcher([]interface{}{1, 2, 3})
+	if err != nil {
+		t.Fatalf("newConsistOfMatcher: %v", err)
+	}
+
+	if matched, message := matcher.Match(actualIDs); !matched {
+		t.Fatalf("expected order-independent match, got: %s", message)
+	}
+}
+
+func TestConsistOfUnexpectedElement(t *testing.T) {
+	actual := decodeJSON(t, `{"ids":[1,2,4]}`)
+	actualIDs := actual.(map[string]interface{})["ids"]
+
+	matcher, err := newConsistOfMatcher([]interface{}{1, 2, 3})
+	if err != nil {
+		t.Fatalf("newConsistOfMatcher: %v", err)
+	}
+
+	if matched, _ := matcher.Match(actualIDs); matched {
+		t.Fatal("expected mismatched element to fail")
+	}
+}
+
+func TestLegacyOperatorsReachableThroughBuildMatcher(t *testing.T) {
+	actual := decodeJSON(t, `{"status":"ok","email":"bob@example.com"}`)
+	expected := map[string]interface{}{
+		"status": map[string]interface{}{"$ne": "error"},
+		"email":  map[string]interface{}{"$regex": "^[a-z]+@"},
+	}
+
+	var errs []Error
+	compareObjects(actual.(map[string]interface{}), expected, "$root", "$root.out", &errs)
+	if len(errs) > 0 {
+		t.Fatalf("expected $ne/$regex to pass, got errors: %+v", errs)
+	}
+}
+
+func TestIsAndIsNotMatchers(t *testing.T) {
+	is := mustBuildMatcher(t, map[string]interface{}{"$is": "$string"})
+	if matched, message := is.Match("hello"); !matched {
+		t.Fatalf("expected $is $string to match a string, got: %s", message)
+	}
+
+	isNot := mustBuildMatcher(t, map[string]interface{}{"$is_not": "$string"})
+	if matched, _ := isNot.Match(42); !matched {
+		t.Fatal("expected $is_not $string to match a non-string value")
+	}
+}
+
+func TestBeNumericallyMatcher(t *testing.T) {
+	matcher, err := newBeNumericallyMatcher(map[string]interface{}{"$op": ">=", "value": 10})
+	if err != nil {
+		t.Fatalf("newBeNumericallyMatcher: %v", err)
+	}
+
+	if matched, _ := matcher.Match(json.Number("12")); !matched {
+		t.Fatal("expected 12 >= 10 to match")
+	}
+	if matched, _ := matcher.Match(json.Number("9")); matched {
+		t.Fatal("expected 9 >= 10 to fail")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// splitPointer parses an RFC 6901 JSON Pointer into its unescaped
+// reference tokens. The empty pointer refers to the whole document.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+
+	rawTokens := strings.Split(pointer[1:], "/")
+	tokens := make([]string, len(rawTokens))
+	for index, token := range rawTokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+		tokens[index] = token
+	}
+	return tokens, nil
+}
+
+// pointerGet resolves pointer against document, per RFC 6901. Numeric
+// tokens address array elements.
+func pointerGet(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("no value at %q", pointer)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("no value at %q", pointer)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("no value at %q", pointer)
+		}
+	}
+	return current, nil
+}
+
+// pointerSet writes value at pointer within document. Intermediate
+// objects/arrays are only created along the way when force is true;
+// otherwise a missing intermediate segment is reported as a "not found"
+// error. A missing object key at the final token is added either way. Numeric
+// tokens address array indices and "-" appends, mirroring the "force set"
+// behavior of typical JSON Pointer implementations.
+func pointerSet(document map[string]interface{}, pointer string, value interface{}, force bool) error {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("cannot set the document root")
+	}
+
+	_, err = setAtPointer(document, tokens, value, force)
+	return err
+}
+
+// setAtPointer descends into container following tokens, creating missing
+// intermediate objects/arrays when force is true, and sets value at the
+// final token. It returns the (possibly reallocated) container, since
+// appending to an array replaces the underlying slice.
+func setAtPointer(container interface{}, tokens []string, value interface{}, force bool) (interface{}, error) {
+	token := tokens[0]
+	last := len(tokens) == 1
+
+	switch node := container.(type) {
+	case map[string]interface{}:
+		child, exists := node[token]
+		if !exists {
+			if last {
+				node[token] = value
+				return node, nil
+			}
+			if !force {
+				return nil, fmt.Errorf("no value at segment %q", token)
+			}
+			child = newContainerFor(tokens[1])
+		}
+
+		if last {
+			node[token] = value
+			return node, nil
+		}
+
+		updatedChild, err := setAtPointer(child, tokens[1:], value, force)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updatedChild
+		return node, nil
+
+	case []interface{}:
+		index := len(node)
+		if token != "-" {
+			parsed, err := strconv.Atoi(token)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid array index %q", token)
+			}
+			index = parsed
+		}
+
+		if index >= len(node) {
+			if !force {
+				return nil, fmt.Errorf("index %q out of range", token)
+			}
+			grown := make([]interface{}, index+1)
+			copy(grown, node)
+			node = grown
+		}
+
+		if last {
+			node[index] = value
+			return node, nil
+		}
+
+		if node[index] == nil {
+			if !force {
+				return nil, fmt.Errorf("no value at segment %q", token)
+			}
+			node[index] = newContainerFor(tokens[1])
+		}
+
+		updatedChild, err := setAtPointer(node[index], tokens[1:], value, force)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updatedChild
+		return node, nil
+
+	default:
+		return nil, fmt.Errorf("cannot descend into a %T", container)
+	}
+}
+
+// newContainerFor picks the container type to create for a missing
+// intermediate segment, based on what the next token looks like.
+func newContainerFor(nextToken string) interface{} {
+	if nextToken == "-" {
+		return []interface{}{}
+	}
+	if _, err := strconv.Atoi(nextToken); err == nil {
+		return []interface{}{}
+	}
+	return map[string]interface{}{}
+}
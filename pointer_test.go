@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestPointerGet(t *testing.T) {
+	document := map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":   "42",
+			"tags": []interface{}{"a", "b"},
+		},
+	}
+
+	value, err := pointerGet(document, "/user/id")
+	if err != nil {
+		t.Fatalf("pointerGet: %v", err)
+	}
+	if value != "42" {
+		t.Fatalf("expected %q, got %v", "42", value)
+	}
+
+	value, err = pointerGet(document, "/user/tags/1")
+	if err != nil {
+		t.Fatalf("pointerGet: %v", err)
+	}
+	if value != "b" {
+		t.Fatalf("expected %q, got %v", "b", value)
+	}
+}
+
+func TestPointerGetMissing(t *testing.T) {
+	document := map[string]interface{}{"user": map[string]interface{}{}}
+
+	if _, err := pointerGet(document, "/user/id"); err == nil {
+		t.Fatal("expected an error for a missing segment")
+	}
+}
+
+func TestPointerSetExistingKey(t *testing.T) {
+	document := map[string]interface{}{"token": "old"}
+
+	if err := pointerSet(document, "/token", "new", false); err != nil {
+		t.Fatalf("pointerSet: %v", err)
+	}
+	if document["token"] != "new" {
+		t.Fatalf("expected token to be overwritten, got %v", document["token"])
+	}
+}
+
+func TestPointerSetWithoutForceFailsOnMissingParent(t *testing.T) {
+	document := map[string]interface{}{}
+
+	if err := pointerSet(document, "/user/id", "42", false); err == nil {
+		t.Fatal("expected an error when the intermediate object is missing and force is false")
+	}
+}
+
+func TestPointerSetWithForceCreatesIntermediates(t *testing.T) {
+	document := map[string]interface{}{}
+
+	if err := pointerSet(document, "/user/id", "42", true); err != nil {
+		t.Fatalf("pointerSet: %v", err)
+	}
+
+	user, ok := document["user"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected /user to be created as an object, got %T", document["user"])
+	}
+	if user["id"] != "42" {
+		t.Fatalf("expected /user/id to be %q, got %v", "42", user["id"])
+	}
+}
+
+func TestPointerSetAppendWithForce(t *testing.T) {
+	document := map[string]interface{}{"tags": []interface{}{"a"}}
+
+	if err := pointerSet(document, "/tags/-", "b", true); err != nil {
+		t.Fatalf("pointerSet: %v", err)
+	}
+
+	tags, ok := document["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[1] != "b" {
+		t.Fatalf("expected tags to be [a b], got %v", document["tags"])
+	}
+}
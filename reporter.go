@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reporter receives the progress of a test run as it happens, so that
+// alternative output formats (JUnit, TAP, a JSON stream, ...) can be
+// plugged in alongside the console dump. processEntry/processStep return
+// their errors instead of printing them; they still print each request
+// and its decoded response to stdout.
+type Reporter interface {
+	StartSuite()
+	StartCase(entry Entry)
+	RecordError(err Error)
+	EndCase(pass bool, duration time.Duration)
+	EndSuite()
+}
+
+// openReportWriter opens path for a reporter to write to, or returns
+// os.Stdout when path is empty so every reporter also works unconfigured.
+func openReportWriter(path string) (*os.File, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+	return os.Create(path)
+}
+
+// parseReportFlag turns the --report flag value into a Reporter. The value
+// is a comma-separated list of "name" or "name:path" entries, e.g.
+// "junit:out.xml,console". An empty value defaults to the console reporter.
+func parseReportFlag(value string) (Reporter, error) {
+	if strings.TrimSpace(value) == "" {
+		return NewConsoleReporter(), nil
+	}
+
+	var reporters []Reporter
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		name, path, _ := strings.Cut(spec, ":")
+		switch name {
+		case "console":
+			reporters = append(reporters, NewConsoleReporter())
+		case "junit":
+			reporters = append(reporters, NewJUnitReporter(path))
+		case "tap":
+			reporters = append(reporters, NewTAPReporter(path))
+		case "json":
+			reporters = append(reporters, NewJSONReporter(path))
+		default:
+			return nil, fmt.Errorf("unknown reporter %q", name)
+		}
+	}
+
+	if len(reporters) == 1 {
+		return reporters[0], nil
+	}
+	return &multiReporter{reporters: reporters}, nil
+}
+
+// multiReporter fans the same events out to every configured reporter, so
+// --report=junit:out.xml,console can run both at once.
+type multiReporter struct {
+	reporters []Reporter
+}
+
+func (m *multiReporter) StartSuite() {
+	for _, r := range m.reporters {
+		r.StartSuite()
+	}
+}
+
+func (m *multiReporter) StartCase(entry Entry) {
+	for _, r := range m.reporters {
+		r.StartCase(entry)
+	}
+}
+
+func (m *multiReporter) RecordError(err Error) {
+	for _, r := range m.reporters {
+		r.RecordError(err)
+	}
+}
+
+func (m *multiReporter) EndCase(pass bool, duration time.Duration) {
+	for _, r := range m.reporters {
+		r.EndCase(pass, duration)
+	}
+}
+
+func (m *multiReporter) EndSuite() {
+	for _, r := range m.reporters {
+		r.EndSuite()
+	}
+}
+
+// ConsoleReporter reproduces the pretty stdout dump Makalu has always
+// printed, one case's errors at a time instead of one big batch at the
+// end of the run.
+type ConsoleReporter struct {
+	currentEntry  Entry
+	pendingErrors []Error
+}
+
+func NewConsoleReporter() *ConsoleReporter {
+	return &ConsoleReporter{}
+}
+
+func (r *ConsoleReporter) StartSuite() {}
+
+func (r *ConsoleReporter) StartCase(entry Entry) {
+	r.currentEntry = entry
+	r.pendingErrors = nil
+}
+
+func (r *ConsoleReporter) RecordError(err Error) {
+	r.pendingErrors = append(r.pendingErrors, err)
+}
+
+func (r *ConsoleReporter) EndCase(pass bool, duration time.Duration) {
+	for _, item := range r.pendingErrors {
+		fmt.Printf(
+			"\n%s\n[%s] %s\n    actual path   -- %s\n    expected path -- %s\n",
+			r.currentEntry.shortName,
+			item.category,
+			item.message,
+			item.actualKey,
+			item.expectedKey,
+		)
+	}
+}
+
+func (r *ConsoleReporter) EndSuite() {}
+
+// junitReporter writes a JUnit-compatible XML report, the format Jenkins
+// and GitLab both understand out of the box.
+type junitReporter struct {
+	path         string
+	cases        []junitTestCase
+	currentEntry Entry
+	currentErrs  []Error
+}
+
+func NewJUnitReporter(path string) *junitReporter {
+	return &junitReporter{path: path}
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name     string         `xml:"name,attr"`
+	Time     string         `xml:"time,attr"`
+	Failures []junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (r *junitReporter) StartSuite() {}
+
+func (r *junitReporter) StartCase(entry Entry) {
+	r.currentEntry = entry
+	r.currentErrs = nil
+}
+
+func (r *junitReporter) RecordError(err Error) {
+	r.currentErrs = append(r.currentErrs, err)
+}
+
+func (r *junitReporter) EndCase(pass bool, duration time.Duration) {
+	testCase := junitTestCase{
+		Name: r.currentEntry.shortName,
+		Time: fmt.Sprintf("%.3f", duration.Seconds()),
+	}
+	for _, failure := range r.currentErrs {
+		testCase.Failures = append(testCase.Failures, junitFailure{
+			Message: failure.message,
+			Text:    failure.actualKey + " != " + failure.expectedKey,
+		})
+	}
+	r.cases = append(r.cases, testCase)
+}
+
+func (r *junitReporter) EndSuite() {
+	suite := junitTestSuite{Name: "makalu", Tests: len(r.cases)}
+	for _, testCase := range r.cases {
+		suite.Failures += len(testCase.Failures)
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	encoded, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		fmt.Printf("Cannot encode JUnit report: %v\n", err)
+		return
+	}
+
+	writer, err := openReportWriter(r.path)
+	if err != nil {
+		fmt.Printf("Cannot write JUnit report to %q: %v\n", r.path, err)
+		return
+	}
+	defer func() {
+		if writer != os.Stdout {
+			writer.Close()
+		}
+	}()
+
+	fmt.Fprint(writer, xml.Header)
+	writer.Write(encoded)
+	fmt.Fprintln(writer)
+}
+
+// tapReporter writes a TAP version 13 stream.
+type tapReporter struct {
+	path         string
+	writer       *os.File
+	currentEntry Entry
+	currentErrs  []Error
+	count        int
+}
+
+func NewTAPReporter(path string) *tapReporter {
+	return &tapReporter{path: path}
+}
+
+func (r *tapReporter) StartSuite() {
+	writer, err := openReportWriter(r.path)
+	if err != nil {
+		fmt.Printf("Cannot write TAP report to %q: %v\n", r.path, err)
+		writer = os.Stdout
+	}
+	r.writer = writer
+	fmt.Fprintln(r.writer, "TAP version 13")
+}
+
+func (r *tapReporter) StartCase(entry Entry) {
+	r.currentEntry = entry
+	r.currentErrs = nil
+}
+
+func (r *tapReporter) RecordError(err Error) {
+	r.currentErrs = append(r.currentErrs, err)
+}
+
+func (r *tapReporter) EndCase(pass bool, duration time.Duration) {
+	r.count++
+	status := "ok"
+	if !pass {
+		status = "not ok"
+	}
+	fmt.Fprintf(r.writer, "%s %d - %s\n", status, r.count, r.currentEntry.shortName)
+	for _, failure := range r.currentErrs {
+		fmt.Fprintln(r.writer, "  ---")
+		fmt.Fprintf(r.writer, "  message: %q\n", failure.message)
+		fmt.Fprintf(r.writer, "  actual: %q\n", failure.actualKey)
+		fmt.Fprintf(r.writer, "  expected: %q\n", failure.expectedKey)
+		fmt.Fprintln(r.writer, "  ...")
+	}
+}
+
+func (r *tapReporter) EndSuite() {
+	fmt.Fprintf(r.writer, "1..%d\n", r.count)
+	if r.writer != os.Stdout {
+		r.writer.Close()
+	}
+}
+
+// jsonReporter writes one JSON object per event (NDJSON), so a consumer
+// can start processing results before the run finishes instead of waiting
+// for one final blob.
+type jsonReporter struct {
+	path         string
+	writer       *os.File
+	encoder      *json.Encoder
+	currentEntry Entry
+}
+
+func NewJSONReporter(path string) *jsonReporter {
+	return &jsonReporter{path: path}
+}
+
+type jsonReportEvent struct {
+	Event       string `json:"event"`
+	Case        string `json:"case,omitempty"`
+	Message     string `json:"message,omitempty"`
+	ActualKey   string `json:"actualKey,omitempty"`
+	ExpectedKey string `json:"expectedKey,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Pass        bool   `json:"pass,omitempty"`
+	DurationMS  int64  `json:"durationMs,omitempty"`
+}
+
+func (r *jsonReporter) StartSuite() {
+	writer, err := openReportWriter(r.path)
+	if err != nil {
+		fmt.Printf("Cannot write JSON report to %q: %v\n", r.path, err)
+		writer = os.Stdout
+	}
+	r.writer = writer
+	r.encoder = json.NewEncoder(writer)
+	r.encoder.Encode(jsonReportEvent{Event: "start_suite"})
+}
+
+func (r *jsonReporter) StartCase(entry Entry) {
+	r.currentEntry = entry
+	r.encoder.Encode(jsonReportEvent{Event: "start_case", Case: entry.shortName})
+}
+
+func (r *jsonReporter) RecordError(err Error) {
+	r.encoder.Encode(jsonReportEvent{
+		Event:       "error",
+		Case:        r.currentEntry.shortName,
+		Message:     err.message,
+		ActualKey:   err.actualKey,
+		ExpectedKey: err.expectedKey,
+		Category:    err.category,
+	})
+}
+
+func (r *jsonReporter) EndCase(pass bool, duration time.Duration) {
+	r.encoder.Encode(jsonReportEvent{
+		Event:      "end_case",
+		Case:       r.currentEntry.shortName,
+		Pass:       pass,
+		DurationMS: duration.Milliseconds(),
+	})
+}
+
+func (r *jsonReporter) EndSuite() {
+	r.encoder.Encode(jsonReportEvent{Event: "end_suite"})
+	if r.writer != os.Stdout {
+		r.writer.Close()
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout redirects os.Stdout for the duration of fn and returns
+// whatever was written to it, for reporters that write there directly
+// instead of through openReportWriter.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	original := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	os.Stdout = writer
+
+	fn()
+
+	writer.Close()
+	os.Stdout = original
+
+	var buffer strings.Builder
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		buffer.WriteString(scanner.Text())
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
+
+func runSampleReport(r Reporter) {
+	r.StartSuite()
+	r.StartCase(Entry{shortName: "sample.yaml"})
+	r.RecordError(Error{message: "mismatch", actualKey: "$root.out.id", expectedKey: "$root.out.id", category: "response_error"})
+	r.EndCase(false, 5*time.Millisecond)
+	r.EndSuite()
+}
+
+func TestConsoleReporterPrintsRecordedErrors(t *testing.T) {
+	output := captureStdout(t, func() {
+		runSampleReport(NewConsoleReporter())
+	})
+
+	if !strings.Contains(output, "sample.yaml") {
+		t.Fatalf("expected output to mention the case name, got: %s", output)
+	}
+	if !strings.Contains(output, "mismatch") {
+		t.Fatalf("expected output to mention the error message, got: %s", output)
+	}
+}
+
+func TestJUnitReporterWritesValidXML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xml")
+	runSampleReport(NewJUnitReporter(path))
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading report: %v", err)
+	}
+
+	var suite junitTestSuite
+	if err := xml.Unmarshal(contents, &suite); err != nil {
+		t.Fatalf("report is not valid XML: %v\n%s", err, contents)
+	}
+
+	if suite.Tests != 1 || suite.Failures != 1 {
+		t.Fatalf("expected 1 test and 1 failure, got %+v", suite)
+	}
+	if len(suite.Cases) != 1 || suite.Cases[0].Name != "sample.yaml" {
+		t.Fatalf("expected a single sample.yaml case, got %+v", suite.Cases)
+	}
+	if len(suite.Cases[0].Failures) != 1 || suite.Cases[0].Failures[0].Message != "mismatch" {
+		t.Fatalf("expected the recorded failure, got %+v", suite.Cases[0].Failures)
+	}
+}
+
+func TestTAPReporterWritesVersion13(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.tap")
+	runSampleReport(NewTAPReporter(path))
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading report: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(contents), "\n"), "\n")
+	if lines[0] != "TAP version 13" {
+		t.Fatalf("expected a TAP version header, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "not ok 1 - sample.yaml") {
+		t.Fatalf("expected a failing test line, got %q", lines[1])
+	}
+	if lines[len(lines)-1] != "1..1" {
+		t.Fatalf("expected a trailing plan line, got %q", lines[len(lines)-1])
+	}
+}
+
+func TestJSONReporterWritesNDJSONEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	runSampleReport(NewJSONReporter(path))
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening report: %v", err)
+	}
+	defer file.Close()
+
+	var events []jsonReportEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event jsonReportEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decoding event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events (start_suite, start_case, error, end_case, end_suite), got %d: %+v", len(events), events)
+	}
+
+	if events[0].Event != "start_suite" {
+		t.Fatalf("expected the first event to be start_suite, got %q", events[0].Event)
+	}
+	if events[1].Event != "start_case" || events[1].Case != "sample.yaml" {
+		t.Fatalf("expected a start_case event for sample.yaml, got %+v", events[1])
+	}
+	if events[2].Event != "error" || events[2].Message != "mismatch" {
+		t.Fatalf("expected the recorded error event, got %+v", events[2])
+	}
+	if events[3].Event != "end_case" || events[3].Pass {
+		t.Fatalf("expected a failing end_case event, got %+v", events[3])
+	}
+}
+
+func TestMultiReporterFansOutToEveryReporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xml")
+	reporter, err := parseReportFlag("console,junit:" + path)
+	if err != nil {
+		t.Fatalf("parseReportFlag: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		runSampleReport(reporter)
+	})
+	if !strings.Contains(output, "sample.yaml") {
+		t.Fatalf("expected the console reporter to still print, got: %s", output)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading JUnit report: %v", err)
+	}
+	if !strings.Contains(string(contents), "sample.yaml") {
+		t.Fatalf("expected the JUnit reporter to also run, got: %s", contents)
+	}
+}
+
+func TestParseReportFlagUnknownReporter(t *testing.T) {
+	if _, err := parseReportFlag("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown reporter name")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+// AuthSpec describes how to authenticate an outgoing request. At most one
+// of Basic or Bearer is expected to be set.
+type AuthSpec struct {
+	Basic  *BasicAuthSpec `yaml:"basic"`
+	Bearer string         `yaml:"bearer"`
+}
+
+// BasicAuthSpec carries HTTP Basic credentials.
+type BasicAuthSpec struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// BodySpec describes how to encode the outgoing request body. Exactly one
+// of JSON, Form, Multipart, or Raw is expected to be set.
+type BodySpec struct {
+	JSON      interface{}            `yaml:"json"`
+	Form      map[string]interface{} `yaml:"form"`
+	Multipart []MultipartField       `yaml:"multipart"`
+	Raw       string                 `yaml:"raw"`
+}
+
+// MultipartField is one part of a multipart/form-data body. Set File to
+// stream a file's contents under that part instead of Value.
+type MultipartField struct {
+	Name     string `yaml:"name"`
+	Value    string `yaml:"value"`
+	File     string `yaml:"file"`
+	Filename string `yaml:"filename"`
+}
+
+// ResponseAssertions holds the response-side checks that run alongside the
+// existing body comparison against Out.
+type ResponseAssertions struct {
+	Status  interface{}            `yaml:"status"`
+	Headers map[string]interface{} `yaml:"headers"`
+}
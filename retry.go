@@ -0,0 +1,96 @@
+package main
+
+import "time"
+
+// RetrySpec mirrors Gomega's Eventually/Consistently assertions in spec
+// form. Exactly one of Until or While is expected to be set:
+//
+//	retry:
+//	  until: pass
+//	  timeout: 30s
+//	  interval: 500ms
+//
+//	retry:
+//	  while: pass
+//	  duration: 5s
+//	  interval: 500ms
+type RetrySpec struct {
+	Until    string `yaml:"until"`
+	Timeout  string `yaml:"timeout"`
+	While    string `yaml:"while"`
+	Duration string `yaml:"duration"`
+	Interval string `yaml:"interval"`
+}
+
+const (
+	defaultRetryTimeout  = 10 * time.Second
+	defaultRetryDuration = 5 * time.Second
+	defaultRetryInterval = time.Second
+)
+
+// runWithRetry runs attempt at least once, applying the Eventually/
+// Consistently semantics described by spec. Every attempt starts from a
+// clean slate: attempt is responsible for resetting whatever per-entry
+// error state it accumulates before returning its own errors, so an
+// intermediate, discarded attempt never leaks into the next one.
+func runWithRetry(spec *RetrySpec, attempt func() []Error) []Error {
+	if spec == nil {
+		return attempt()
+	}
+
+	switch {
+	case spec.Until != "":
+		return retryUntilPass(spec, attempt)
+	case spec.While != "":
+		return retryWhilePass(spec, attempt)
+	default:
+		return attempt()
+	}
+}
+
+// retryUntilPass implements Eventually semantics: keep retrying until an
+// attempt reports no errors, or the timeout elapses.
+func retryUntilPass(spec *RetrySpec, attempt func() []Error) []Error {
+	timeout := parseDurationOrDefault(spec.Timeout, defaultRetryTimeout)
+	interval := parseDurationOrDefault(spec.Interval, defaultRetryInterval)
+	deadline := time.Now().Add(timeout)
+
+	var lastErrors []Error
+	for {
+		lastErrors = attempt()
+		if len(lastErrors) == 0 || time.Now().After(deadline) {
+			return lastErrors
+		}
+		time.Sleep(interval)
+	}
+}
+
+// retryWhilePass implements Consistently semantics: the assertion must
+// hold for every attempt made over the duration; the first failing
+// attempt, or the last attempt once the duration elapses, is returned.
+func retryWhilePass(spec *RetrySpec, attempt func() []Error) []Error {
+	duration := parseDurationOrDefault(spec.Duration, defaultRetryDuration)
+	interval := parseDurationOrDefault(spec.Interval, defaultRetryInterval)
+	deadline := time.Now().Add(duration)
+
+	var lastErrors []Error
+	for {
+		lastErrors = attempt()
+		if len(lastErrors) > 0 || time.Now().After(deadline) {
+			return lastErrors
+		}
+		time.Sleep(interval)
+	}
+}
+
+func parseDurationOrDefault(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return duration
+}
@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestRunWithRetryNilSpecRunsOnce(t *testing.T) {
+	calls := 0
+	errs := runWithRetry(nil, func() []Error {
+		calls++
+		return []Error{{message: "boom"}}
+	})
+
+	if calls != 1 {
+		t.Fatalf("expected exactly one attempt, got %d", calls)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected the attempt's errors to pass through, got %v", errs)
+	}
+}
+
+func TestRetryUntilPassStopsOnFirstSuccess(t *testing.T) {
+	calls := 0
+	spec := &RetrySpec{Until: "pass", Timeout: "200ms", Interval: "5ms"}
+
+	errs := runWithRetry(spec, func() []Error {
+		calls++
+		if calls < 3 {
+			return []Error{{message: "not yet"}}
+		}
+		return nil
+	})
+
+	if calls != 3 {
+		t.Fatalf("expected to stop at the third attempt, got %d calls", calls)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors from the passing attempt, got %v", errs)
+	}
+}
+
+func TestRetryUntilPassStopsAtTimeout(t *testing.T) {
+	calls := 0
+	spec := &RetrySpec{Until: "pass", Timeout: "20ms", Interval: "5ms"}
+
+	errs := runWithRetry(spec, func() []Error {
+		calls++
+		return []Error{{message: "always failing"}}
+	})
+
+	if calls < 2 {
+		t.Fatalf("expected more than one attempt before the timeout, got %d", calls)
+	}
+	if len(errs) == 0 {
+		t.Fatal("expected the last failing attempt's errors to be returned")
+	}
+}
+
+func TestRetryWhilePassStopsOnFirstFailure(t *testing.T) {
+	calls := 0
+	spec := &RetrySpec{While: "pass", Duration: "200ms", Interval: "5ms"}
+
+	errs := runWithRetry(spec, func() []Error {
+		calls++
+		if calls == 3 {
+			return []Error{{message: "broke"}}
+		}
+		return nil
+	})
+
+	if calls != 3 {
+		t.Fatalf("expected to stop at the first failing attempt, got %d calls", calls)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected the failing attempt's errors, got %v", errs)
+	}
+}
+
+func TestRetryWhilePassRunsUntilDuration(t *testing.T) {
+	calls := 0
+	spec := &RetrySpec{While: "pass", Duration: "20ms", Interval: "5ms"}
+
+	errs := runWithRetry(spec, func() []Error {
+		calls++
+		return nil
+	})
+
+	if calls < 2 {
+		t.Fatalf("expected more than one attempt before the duration elapsed, got %d", calls)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("expected the last passing attempt's errors, got %v", errs)
+	}
+}
+
+func TestParseDurationOrDefault(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"empty value falls back", "", defaultRetryTimeout.String()},
+		{"invalid value falls back", "not a duration", defaultRetryTimeout.String()},
+		{"valid value is parsed", "250ms", "250ms"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := parseDurationOrDefault(c.value, defaultRetryTimeout).String(); got != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, got)
+			}
+		})
+	}
+}
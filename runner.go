@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Runner sends one HTTP request built from a rendered step and decodes the
+// response. Pulling this plumbing out of processStep means it can be
+// exercised directly against an httptest.Server, without shelling out.
+type Runner struct {
+	Client *http.Client
+}
+
+func NewRunner() *Runner {
+	return &Runner{Client: http.DefaultClient}
+}
+
+// RunnerRequest is the fully rendered (post-templating) description of an
+// HTTP call.
+type RunnerRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]interface{}
+	Query   map[string]interface{}
+	Auth    *AuthSpec
+	Body    *BodySpec
+}
+
+// RunnerResponse is the decoded result of an HTTP call.
+type RunnerResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       interface{}
+	Raw        []byte
+}
+
+func (r *Runner) Do(request RunnerRequest) (*RunnerResponse, error) {
+	httpRequest, err := buildHTTPRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse, err := r.Client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	raw, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := decodeBody(httpResponse.Header.Get("Content-Type"), raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunnerResponse{
+		StatusCode: httpResponse.StatusCode,
+		Headers:    httpResponse.Header,
+		Body:       body,
+		Raw:        raw,
+	}, nil
+}
+
+func buildHTTPRequest(request RunnerRequest) (*http.Request, error) {
+	requestURL, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL %q: %w", request.URL, err)
+	}
+
+	if len(request.Query) > 0 {
+		query := requestURL.Query()
+		for key, value := range request.Query {
+			query.Set(key, fmt.Sprintf("%v", value))
+		}
+		requestURL.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	contentType := ""
+	if request.Body != nil {
+		encoded, encodedContentType, err := encodeBody(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = encoded
+		contentType = encodedContentType
+	}
+
+	httpRequest, err := http.NewRequest(request.Method, requestURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		httpRequest.Header.Set("Content-Type", contentType)
+	}
+
+	for key, value := range request.Headers {
+		httpRequest.Header.Set(key, fmt.Sprintf("%v", value))
+	}
+
+	if request.Auth != nil {
+		if request.Auth.Basic != nil {
+			httpRequest.SetBasicAuth(request.Auth.Basic.Username, request.Auth.Basic.Password)
+		} else if request.Auth.Bearer != "" {
+			httpRequest.Header.Set("Authorization", "Bearer "+request.Auth.Bearer)
+		}
+	}
+
+	return httpRequest, nil
+}
+
+func encodeBody(body *BodySpec) (io.Reader, string, error) {
+	switch {
+	case body.JSON != nil:
+		encoded, err := json.Marshal(body.JSON)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(encoded), "application/json", nil
+
+	case body.Form != nil:
+		values := url.Values{}
+		for key, value := range body.Form {
+			values.Set(key, fmt.Sprintf("%v", value))
+		}
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+
+	case body.Multipart != nil:
+		var buffer bytes.Buffer
+		writer := multipart.NewWriter(&buffer)
+		for _, field := range body.Multipart {
+			if field.File != "" {
+				fileWriter, err := writer.CreateFormFile(field.Name, multipartFileName(field))
+				if err != nil {
+					return nil, "", err
+				}
+				fileContents, err := os.ReadFile(field.File)
+				if err != nil {
+					return nil, "", err
+				}
+				if _, err := fileWriter.Write(fileContents); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buffer, writer.FormDataContentType(), nil
+
+	case body.Raw != "":
+		return strings.NewReader(body.Raw), "", nil
+	}
+
+	return nil, "", nil
+}
+
+func multipartFileName(field MultipartField) string {
+	if field.Filename != "" {
+		return field.Filename
+	}
+	return filepath.Base(field.File)
+}
+
+// decodeBody branches on Content-Type so only JSON responses are decoded as
+// JSON; everything else (text, XML, ...) is kept as the raw string instead
+// of failing to decode. An empty body (e.g. a 204) decodes to nil.
+func decodeBody(contentType string, raw []byte) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	if strings.Contains(contentType, "json") {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, fmt.Errorf("invalid JSON response: %w", err)
+		}
+		return decoded, nil
+	}
+
+	return string(raw), nil
+}
+
+// renderAuth applies {{ ... }} templating to the parts of an auth spec
+// that may reference earlier steps, e.g. a bearer token captured at login.
+func renderAuth(auth *AuthSpec, context map[string]interface{}) *AuthSpec {
+	if auth == nil {
+		return nil
+	}
+
+	rendered := &AuthSpec{}
+	if auth.Bearer != "" {
+		rendered.Bearer, _ = renderTemplates(auth.Bearer, context).(string)
+	}
+	if auth.Basic != nil {
+		username, _ := renderTemplates(auth.Basic.Username, context).(string)
+		password, _ := renderTemplates(auth.Basic.Password, context).(string)
+		rendered.Basic = &BasicAuthSpec{Username: username, Password: password}
+	}
+	return rendered
+}
+
+// renderBody applies {{ ... }} templating to the outgoing body. legacyIn is
+// the old top-level `in:` field, kept as sugar for `body: { json: ... }`
+// when body itself isn't given.
+func renderBody(body *BodySpec, legacyIn map[string]interface{}, context map[string]interface{}) *BodySpec {
+	if body == nil {
+		if legacyIn == nil {
+			return nil
+		}
+		rendered, _ := renderTemplates(legacyIn, context).(map[string]interface{})
+		return &BodySpec{JSON: rendered}
+	}
+
+	rendered := &BodySpec{}
+	if body.JSON != nil {
+		rendered.JSON = renderTemplates(body.JSON, context)
+	}
+	if body.Form != nil {
+		rendered.Form, _ = renderTemplates(body.Form, context).(map[string]interface{})
+	}
+	if body.Multipart != nil {
+		rendered.Multipart = make([]MultipartField, len(body.Multipart))
+		for index, field := range body.Multipart {
+			value, _ := renderTemplates(field.Value, context).(string)
+			rendered.Multipart[index] = MultipartField{
+				Name:     field.Name,
+				Value:    value,
+				File:     field.File,
+				Filename: field.Filename,
+			}
+		}
+	}
+	if body.Raw != "" {
+		rendered.Raw, _ = renderTemplates(body.Raw, context).(string)
+	}
+	return rendered
+}
+
+// checkResponseAssertions runs the response-side status/headers checks
+// added alongside the existing body comparison against Out.
+func checkResponseAssertions(assertions *ResponseAssertions, response *RunnerResponse, errs *[]Error) {
+	if assertions.Status != nil {
+		matcher, err := buildMatcher(assertions.Status)
+		if err != nil {
+			*errs = append(*errs, Error{
+				message:     err.Error(),
+				actualKey:   "$root.status",
+				expectedKey: "$root.response.status",
+				category:    "spec_error",
+			})
+		} else if matched, message := matcher.Match(response.StatusCode); !matched {
+			*errs = append(*errs, Error{
+				message:     message,
+				actualKey:   "$root.status",
+				expectedKey: "$root.response.status",
+				category:    "response_error",
+			})
+		}
+	}
+
+	for name, expected := range assertions.Headers {
+		matcher, err := buildMatcher(expected)
+		if err != nil {
+			*errs = append(*errs, Error{
+				message:     err.Error(),
+				actualKey:   "$root.headers." + name,
+				expectedKey: "$root.response.headers." + name,
+				category:    "spec_error",
+			})
+			continue
+		}
+
+		actual := response.Headers.Get(name)
+		if matched, message := matcher.Match(actual); !matched {
+			*errs = append(*errs, Error{
+				message:     message,
+				actualKey:   "$root.headers." + name,
+				expectedKey: "$root.response.headers." + name,
+				category:    "response_error",
+			})
+		}
+	}
+}
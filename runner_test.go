@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRunnerDoDecodesJSONResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected bearer auth, got %q", r.Header.Get("Authorization"))
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding request body: %v", err)
+		}
+		if body["name"] != "bob" {
+			t.Errorf("expected name bob, got %v", body["name"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	runner := NewRunner()
+	response, err := runner.Do(RunnerRequest{
+		Method:  "POST",
+		URL:     server.URL + "/users",
+		Headers: map[string]interface{}{"X-Test": "1"},
+		Auth:    &AuthSpec{Bearer: "secret"},
+		Body:    &BodySpec{JSON: map[string]interface{}{"name": "bob"}},
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", response.StatusCode)
+	}
+
+	body, ok := response.Body.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected a decoded JSON object, got %T", response.Body)
+	}
+	if id, ok := body["id"].(json.Number); !ok || id.String() != "42" {
+		t.Fatalf("expected id 42 as json.Number, got %v", body["id"])
+	}
+}
+
+func TestRunnerDoKeepsNonJSONBodyAsString(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	runner := NewRunner()
+	response, err := runner.Do(RunnerRequest{Method: "GET", URL: server.URL + "/ping"})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	if response.Body != "pong" {
+		t.Fatalf("expected raw body %q, got %v", "pong", response.Body)
+	}
+}
+
+func TestRunnerDoEncodesQueryParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "2" {
+			t.Errorf("expected page=2, got %q", r.URL.Query().Get("page"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	runner := NewRunner()
+	response, err := runner.Do(RunnerRequest{
+		Method: "GET",
+		URL:    server.URL + "/items",
+		Query:  map[string]interface{}{"page": 2},
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", response.StatusCode)
+	}
+	if response.Body != nil {
+		t.Fatalf("expected an empty body for 204, got %v", response.Body)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import "gopkg.in/yaml.v3"
+
+// Step is one entry of a multi-step test case. Steps run in order, and
+// each one's response is captured into context["steps"][step.Name] so
+// later steps can refer back to it, e.g. {{ steps.login.out.token }}.
+type Step struct {
+	Name     string                 `yaml:"name"`
+	Target   string                 `yaml:"target"`
+	Headers  map[string]interface{} `yaml:"headers"`
+	Query    map[string]interface{} `yaml:"query"`
+	Auth     *AuthSpec              `yaml:"auth"`
+	In       map[string]interface{} `yaml:"in"`
+	Body     *BodySpec              `yaml:"body"`
+	Out      map[string]interface{} `yaml:"out"`
+	Response *ResponseAssertions    `yaml:"response"`
+	Retry    *RetrySpec             `yaml:"retry"`
+	Capture  map[string]Capture     `yaml:"capture"`
+}
+
+// Capture pulls a value out of a step's response and places it at a JSON
+// Pointer destination within that step's captured value tree. It can be
+// written in shorthand as the source pointer directly:
+//
+//	capture:
+//	  /token: /access_token
+//
+// or in full form when intermediate objects need to be created:
+//
+//	capture:
+//	  /user/id: { from: /user/id, force: true }
+type Capture struct {
+	From  string `yaml:"from"`
+	Force bool   `yaml:"force"`
+}
+
+func (c *Capture) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.From = node.Value
+		return nil
+	}
+
+	type rawCapture Capture
+	var raw rawCapture
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	*c = Capture(raw)
+	return nil
+}
+
+// buildStepContext assembles the value that later steps see as
+// context["steps"][stepName]. The full decoded response is always
+// available under "out", matching the $root.out convention compareObjects
+// already uses; capture entries additionally reshape specific fields into
+// the tree at whatever destination pointer they name.
+func buildStepContext(stepName string, response map[string]interface{}, capture map[string]Capture, errs *[]Error) map[string]interface{} {
+	stepContext := map[string]interface{}{"out": response}
+
+	for destination, c := range capture {
+		value, err := pointerGet(response, c.From)
+		if err != nil {
+			*errs = append(*errs, Error{
+				message:     "Cannot capture " + c.From + ": " + err.Error(),
+				actualKey:   "$root.out" + c.From,
+				expectedKey: "$root.capture" + destination,
+				category:    "spec_error",
+			})
+			continue
+		}
+
+		if err := pointerSet(stepContext, destination, value, c.Force); err != nil {
+			*errs = append(*errs, Error{
+				message:     "Cannot capture into " + destination + ": " + err.Error(),
+				actualKey:   "$root.out" + c.From,
+				expectedKey: "$root.capture" + destination,
+				category:    "spec_error",
+			})
+		}
+	}
+
+	return stepContext
+}
+
+// renderTemplates recursively applies {{ ... }} templating to every string
+// found in value, using refer against context.
+func renderTemplates(value interface{}, context map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return refer(v, context)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			rendered[key] = renderTemplates(nested, context)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for index, nested := range v {
+			rendered[index] = renderTemplates(nested, context)
+		}
+		return rendered
+	default:
+		return value
+	}
+}